@@ -0,0 +1,158 @@
+// Package config loads the declarative pipeline.yaml/buildkitegen.yaml that
+// describes which Buildkite steps buildkitegen should emit, what commands
+// they run, and which changed-file glob patterns gate them.
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RetryPolicy mirrors the automatic-retry knobs buildkite.RetryComplexAutomatic
+// exposes, trimmed down to what pipeline.yaml needs to declare.
+type RetryPolicy struct {
+	Limit int64 `yaml:"limit"`
+}
+
+// Matrix declares a build matrix dimension for a step, e.g. a list of
+// partition shards. It is intentionally minimal today; it exists so steps
+// that need more than a single Parallelism axis have somewhere to grow into.
+type Matrix struct {
+	Setup []string `yaml:"setup,omitempty"`
+}
+
+// PathRule is a named group of glob patterns. A step matches a rule if any
+// pattern matches any changed file. Patterns use doublestar syntax, e.g.
+// "**/*.rs" or "ci/buildkitegen/**".
+type PathRule struct {
+	Patterns []string `yaml:"patterns"`
+}
+
+// StepDef is one entry in pipeline.yaml: either a command step, or a bare
+// wait barrier (Wait: true) separating the steps around it.
+type StepDef struct {
+	// Wait marks this entry as a `wait` step; all other fields are ignored.
+	Wait bool `yaml:"wait,omitempty"`
+
+	Name             string `yaml:"name,omitempty"`
+	Command          string `yaml:"command,omitempty"`
+	TimeoutInMinutes int64  `yaml:"timeout_in_minutes,omitempty"`
+	Queue            string `yaml:"queue,omitempty"`
+	Parallelism      int    `yaml:"parallelism,omitempty"`
+	// ZeroIndexedShard selects shard numbering passed into Command: false
+	// (default) formats Command with a 1-based shard index matching the
+	// step's display name, true formats it with a 0-based index.
+	ZeroIndexedShard bool         `yaml:"zero_indexed_shard,omitempty"`
+	Retry            *RetryPolicy `yaml:"retry,omitempty"`
+	Matrix           *Matrix      `yaml:"matrix,omitempty"`
+
+	// TimingDriven opts a parallel step into historical-timing-based shard
+	// assignment (see the partition package) instead of plain
+	// index-modulo-Parallelism splitting.
+	TimingDriven bool `yaml:"timing_driven,omitempty"`
+	// TestListCommand is the command format used when a timing-driven
+	// assignment is available; it takes a single %s for the shard's
+	// comma-separated test list. Ignored unless TimingDriven is set.
+	TestListCommand string `yaml:"test_list_command,omitempty"`
+	// ArtifactPath is the JUnit report artifact path each shard of this
+	// step uploads, used to look up historical per-test durations.
+	ArtifactPath string `yaml:"artifact_path,omitempty"`
+
+	// AlwaysRun steps are emitted on every build (push or PR), regardless of
+	// Paths. Used for things like the sanity check.
+	AlwaysRun bool `yaml:"always_run,omitempty"`
+
+	// Paths names a PathRule (by key, see Config.PathRules) whose patterns
+	// must match at least one changed file for this step to be emitted on a
+	// PR build. Ignored (step always runs) on push builds.
+	Paths string `yaml:"paths,omitempty"`
+}
+
+// Config is the top-level shape of pipeline.yaml.
+type Config struct {
+	// Queues lists the Buildkite agent queues steps are allowed to target.
+	// Loading fails if a step references a queue not in this list.
+	Queues []string `yaml:"queues"`
+
+	// PathRules maps a rule name (referenced from StepDef.Paths) to the glob
+	// patterns that make it match.
+	PathRules map[string]PathRule `yaml:"path_rules"`
+
+	Steps []StepDef `yaml:"steps"`
+}
+
+// LoadFile reads and validates the config at path.
+func LoadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config %q: %w", path, err)
+	}
+	return Load(data)
+}
+
+// Load parses and validates pipeline config contents. Unknown YAML keys are
+// rejected so typos in pipeline.yaml fail loudly instead of being silently
+// ignored.
+func Load(data []byte) (*Config, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+func (c *Config) validate() error {
+	queues := make(map[string]bool, len(c.Queues))
+	for _, q := range c.Queues {
+		queues[q] = true
+	}
+
+	names := make(map[string]bool, len(c.Steps))
+	for _, step := range c.Steps {
+		if step.Wait {
+			continue
+		}
+
+		if step.Name == "" {
+			return fmt.Errorf("config: step has no name")
+		}
+		if names[step.Name] {
+			return fmt.Errorf("config: duplicate step name %q", step.Name)
+		}
+		names[step.Name] = true
+
+		if step.Command == "" {
+			return fmt.Errorf("config: step %q has no command", step.Name)
+		}
+		if step.Queue == "" {
+			return fmt.Errorf("config: step %q has no queue", step.Name)
+		}
+		if !queues[step.Queue] {
+			return fmt.Errorf("config: step %q references undeclared queue %q", step.Name, step.Queue)
+		}
+		if step.Paths != "" {
+			if _, ok := c.PathRules[step.Paths]; !ok {
+				return fmt.Errorf("config: step %q references undeclared path rule %q", step.Name, step.Paths)
+			}
+		}
+		if !step.AlwaysRun && step.Paths == "" {
+			return fmt.Errorf("config: step %q must set either always_run or paths", step.Name)
+		}
+		if step.TimingDriven && (step.TestListCommand == "" || step.ArtifactPath == "") {
+			return fmt.Errorf("config: step %q sets timing_driven but is missing test_list_command or artifact_path", step.Name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package config
+
+import "testing"
+
+const sampleConfig = `
+queues:
+  - check
+
+path_rules:
+  rust:
+    patterns:
+      - "**/*.rs"
+
+steps:
+  - name: sanity
+    command: ci/test-sanity.sh
+    timeout_in_minutes: 10
+    queue: check
+    always_run: true
+
+  - name: check
+    command: ci/test-checks.sh
+    timeout_in_minutes: 20
+    queue: check
+    paths: rust
+`
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load([]byte(sampleConfig))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Steps) != 2 {
+		t.Fatalf("got %d steps, want 2", len(cfg.Steps))
+	}
+}
+
+func TestLoadRejectsUnknownQueue(t *testing.T) {
+	const bad = `
+queues:
+  - check
+steps:
+  - name: sanity
+    command: ci/test-sanity.sh
+    queue: solana
+    always_run: true
+`
+	if _, err := Load([]byte(bad)); err == nil {
+		t.Fatal("expected error for undeclared queue, got nil")
+	}
+}
+
+func TestLoadRejectsUnknownField(t *testing.T) {
+	const bad = `
+queues:
+  - check
+steps:
+  - name: sanity
+    command: ci/test-sanity.sh
+    queue: check
+    always_run: true
+    bogus_field: true
+`
+	if _, err := Load([]byte(bad)); err == nil {
+		t.Fatal("expected error for unknown field, got nil")
+	}
+}
+
+func TestSelected(t *testing.T) {
+	cfg, err := Load([]byte(sampleConfig))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	sanity, check := cfg.Steps[0], cfg.Steps[1]
+
+	if selected, _ := cfg.Selected(sanity, false, nil); !selected {
+		t.Error("always_run step should be selected on PR builds with no changed files")
+	}
+
+	if selected, _ := cfg.Selected(check, false, []string{"README.md"}); selected {
+		t.Error("path-gated step should not be selected when no changed file matches")
+	}
+
+	selected, match := cfg.Selected(check, false, []string{"core/lib.rs"})
+	if !selected {
+		t.Fatal("path-gated step should be selected when a changed file matches")
+	}
+	if match.File != "core/lib.rs" || match.Pattern != "**/*.rs" {
+		t.Errorf("unexpected match %+v", match)
+	}
+}
@@ -0,0 +1,34 @@
+package config
+
+import "github.com/bmatcuk/doublestar/v4"
+
+// MatchResult records which pattern matched which changed file, so
+// --dry-run can explain why a step was (or wasn't) selected.
+type MatchResult struct {
+	Pattern string
+	File    string
+}
+
+// Matches reports whether the named path rule matches any of changedFiles,
+// along with the first (pattern, file) pair that matched.
+func (c *Config) Matches(rule string, changedFiles []string) (bool, MatchResult) {
+	patterns := c.PathRules[rule].Patterns
+	for _, pattern := range patterns {
+		for _, file := range changedFiles {
+			if ok, _ := doublestar.Match(pattern, file); ok {
+				return true, MatchResult{Pattern: pattern, File: file}
+			}
+		}
+	}
+	return false, MatchResult{}
+}
+
+// Selected reports whether step should be emitted given the build context.
+// isPush builds always emit every step; PR builds only emit steps that are
+// AlwaysRun or whose path rule matches a changed file.
+func (c *Config) Selected(step StepDef, isPush bool, changedFiles []string) (bool, MatchResult) {
+	if isPush || step.AlwaysRun {
+		return true, MatchResult{}
+	}
+	return c.Matches(step.Paths, changedFiles)
+}
@@ -0,0 +1,76 @@
+package ghsource
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Kind classifies what BUILDKITE_BRANCH refers to.
+type Kind int
+
+const (
+	// KindPush is a direct push to a branch with no associated pull
+	// request (or one we can't otherwise identify); every step runs.
+	KindPush Kind = iota
+	// KindPullRequest is a `pull/N/head` branch: diff via the GitHub API.
+	KindPullRequest
+	// KindMergeQueue is a `gh-readonly-queue/<base>/pr-<N>-<sha>` branch:
+	// diff the queue commit against its base locally instead of asking the
+	// GitHub API for PR files, since the PR may already be merged/closed by
+	// the time the queue build runs.
+	KindMergeQueue
+	// KindReleaseBranch is a `vX.Y`-shaped release branch: diff against the
+	// merge base with the previous release tag.
+	KindReleaseBranch
+)
+
+// Branch is the parsed shape of BUILDKITE_BRANCH.
+type Branch struct {
+	Kind Kind
+
+	// PRNumber is set for KindPullRequest and KindMergeQueue.
+	PRNumber int
+
+	// BaseRef is the ref to diff against for KindMergeQueue (the queue's
+	// target branch) and KindReleaseBranch (the previous release tag).
+	BaseRef string
+
+	// HeadSHA is the merge-queue commit to diff, set for KindMergeQueue.
+	HeadSHA string
+
+	// Major/Minor are set for KindReleaseBranch.
+	Major, Minor int
+}
+
+var (
+	pullRequestRe = regexp.MustCompile(`^pull/(\d+)/head$`)
+	mergeQueueRe  = regexp.MustCompile(`^gh-readonly-queue/([^/]+)/pr-(\d+)-([0-9a-f]+)$`)
+	releaseRe     = regexp.MustCompile(`^(?:refs/heads/)?v(\d+)\.(\d+)$`)
+)
+
+// ParseBranch classifies a BUILDKITE_BRANCH value.
+func ParseBranch(branch string) Branch {
+	if m := pullRequestRe.FindStringSubmatch(branch); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return Branch{Kind: KindPullRequest, PRNumber: n}
+	}
+
+	if m := mergeQueueRe.FindStringSubmatch(branch); m != nil {
+		n, _ := strconv.Atoi(m[2])
+		return Branch{Kind: KindMergeQueue, BaseRef: m[1], PRNumber: n, HeadSHA: m[3]}
+	}
+
+	if m := releaseRe.FindStringSubmatch(branch); m != nil {
+		major, _ := strconv.Atoi(m[1])
+		minor, _ := strconv.Atoi(m[2])
+		return Branch{Kind: KindReleaseBranch, Major: major, Minor: minor}
+	}
+
+	return Branch{Kind: KindPush}
+}
+
+// String renders the release version this Branch describes, e.g. "v1.18".
+func (b Branch) String() string {
+	return fmt.Sprintf("v%d.%d", b.Major, b.Minor)
+}
@@ -0,0 +1,39 @@
+package ghsource
+
+import "testing"
+
+func TestParseBranchPullRequest(t *testing.T) {
+	b := ParseBranch("pull/123/head")
+	if b.Kind != KindPullRequest || b.PRNumber != 123 {
+		t.Fatalf("got %+v", b)
+	}
+}
+
+func TestParseBranchMergeQueue(t *testing.T) {
+	b := ParseBranch("gh-readonly-queue/main/pr-456-deadbeef")
+	if b.Kind != KindMergeQueue {
+		t.Fatalf("got kind %v, want KindMergeQueue", b.Kind)
+	}
+	if b.BaseRef != "main" || b.PRNumber != 456 || b.HeadSHA != "deadbeef" {
+		t.Fatalf("got %+v", b)
+	}
+}
+
+func TestParseBranchRelease(t *testing.T) {
+	for _, branch := range []string{"v1.18", "refs/heads/v1.18"} {
+		b := ParseBranch(branch)
+		if b.Kind != KindReleaseBranch || b.Major != 1 || b.Minor != 18 {
+			t.Fatalf("ParseBranch(%q) = %+v", branch, b)
+		}
+		if b.String() != "v1.18" {
+			t.Errorf("String() = %q, want v1.18", b.String())
+		}
+	}
+}
+
+func TestParseBranchPush(t *testing.T) {
+	b := ParseBranch("master")
+	if b.Kind != KindPush {
+		t.Fatalf("got kind %v, want KindPush", b.Kind)
+	}
+}
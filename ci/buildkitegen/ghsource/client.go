@@ -0,0 +1,29 @@
+// Package ghsource resolves the set of changed files (and whether the build
+// is a push rather than a PR/merge-queue/release-branch build) for whatever
+// BUILDKITE_BRANCH buildkitegen was invoked with.
+package ghsource
+
+import (
+	"context"
+	"os"
+
+	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+)
+
+// NewClient returns a go-github client. If GITHUB_TOKEN or
+// BUILDKITE_GITHUB_APP_TOKEN is set, the client authenticates with it,
+// raising the GitHub API rate limit from 60/hr to 5000/hr; otherwise it
+// falls back to the same unauthenticated client buildkitegen always used.
+func NewClient(ctx context.Context) *github.Client {
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		token = os.Getenv("BUILDKITE_GITHUB_APP_TOKEN")
+	}
+	if token == "" {
+		return github.NewClient(nil)
+	}
+
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+	return github.NewClient(oauth2.NewClient(ctx, ts))
+}
@@ -0,0 +1,86 @@
+package ghsource
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// diffNameOnly returns the files changed between the merge base of head and
+// base, and head itself.
+func diffNameOnly(ctx context.Context, base, head string) ([]string, error) {
+	mergeBase, err := mergeBase(ctx, base, head)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := git(ctx, "diff", "--name-only", mergeBase, head)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
+
+func mergeBase(ctx context.Context, base, head string) (string, error) {
+	out, err := git(ctx, "merge-base", base, head)
+	if err != nil {
+		return "", fmt.Errorf("git merge-base %s %s: %w", base, head, err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func git(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, string(exitErr.Stderr))
+		}
+		return "", fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
+
+// previousReleaseTag finds the highest vMAJOR.MINOR.PATCH tag for the
+// release immediately before major.minor, e.g. for v2.2 it returns the
+// highest v2.1.* tag. It returns "" if no such tag exists.
+func previousReleaseTag(ctx context.Context, major, minor int) (string, error) {
+	out, err := git(ctx, "tag", "--list", fmt.Sprintf("v%d.%d.*", major, minor-1))
+	if err != nil {
+		return "", err
+	}
+
+	var tags []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if line != "" {
+			tags = append(tags, line)
+		}
+	}
+	if len(tags) == 0 {
+		return "", nil
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		return patchOf(tags[i]) < patchOf(tags[j])
+	})
+	return tags[len(tags)-1], nil
+}
+
+func patchOf(tag string) int {
+	i := strings.LastIndex(tag, ".")
+	if i == -1 {
+		return 0
+	}
+	n, _ := strconv.Atoi(tag[i+1:])
+	return n
+}
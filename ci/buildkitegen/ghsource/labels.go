@@ -0,0 +1,58 @@
+package ghsource
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/github"
+)
+
+// Labels returns the labels on the given PR/issue number.
+func Labels(ctx context.Context, client *github.Client, number int) ([]string, error) {
+	var names []string
+	opt := &github.ListOptions{PerPage: 100}
+
+	for {
+		labels, resp, err := client.Issues.ListLabelsByIssue(ctx, Owner, Repo, number, opt)
+		if err != nil {
+			return nil, fmt.Errorf("list labels for #%d: %w", number, err)
+		}
+
+		for _, l := range labels {
+			names = append(names, *l.Name)
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return names, nil
+}
+
+// HasLabel reports whether name is present in labels.
+func HasLabel(labels []string, name string) bool {
+	for _, l := range labels {
+		if l == name {
+			return true
+		}
+	}
+	return false
+}
+
+// trustedAuthorAssociations are the GitHub "author_association" values that
+// imply write access to the repository (and therefore the ability to apply
+// labels directly), as opposed to an external contributor's own PR body or
+// commit messages, which anyone can edit.
+var trustedAuthorAssociations = map[string]bool{
+	"OWNER":        true,
+	"MEMBER":       true,
+	"COLLABORATOR": true,
+}
+
+// IsTrustedAuthor reports whether association (a PullRequest's
+// AuthorAssociation) belongs to someone with write access to the repo.
+func IsTrustedAuthor(association string) bool {
+	return trustedAuthorAssociations[association]
+}
@@ -0,0 +1,70 @@
+package ghsource
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/google/go-github/github"
+)
+
+// Owner/Repo of the repository buildkitegen generates pipelines for.
+const (
+	Owner = "anza-xyz"
+	Repo  = "agave"
+)
+
+// Resolve figures out, for the given BUILDKITE_BRANCH, whether this is a
+// push build (run everything) or a selective build, and if selective, which
+// files changed. prNumber is the associated pull request number for
+// KindPullRequest and KindMergeQueue builds, and 0 otherwise.
+func Resolve(ctx context.Context, client *github.Client, branch string) (isPush bool, prNumber int, changedFiles []string, err error) {
+	b := ParseBranch(branch)
+	baseRefOverride := os.Getenv("BUILDKITEGEN_BASE_REF")
+
+	switch b.Kind {
+	case KindPullRequest:
+		fmt.Fprintf(os.Stderr, "Extracted pull request number: %d\n", b.PRNumber)
+		files, err := ListFiles(ctx, client, Owner, Repo, b.PRNumber)
+		if err != nil {
+			return false, b.PRNumber, nil, fmt.Errorf("list PR files: %w", err)
+		}
+		return false, b.PRNumber, files, nil
+
+	case KindMergeQueue:
+		base := b.BaseRef
+		if baseRefOverride != "" {
+			base = baseRefOverride
+		}
+		fmt.Fprintf(os.Stderr, "Merge-queue build for PR #%d against %s\n", b.PRNumber, base)
+		files, err := diffNameOnly(ctx, base, "HEAD")
+		if err != nil {
+			return false, b.PRNumber, nil, fmt.Errorf("diff merge-queue commit: %w", err)
+		}
+		return false, b.PRNumber, files, nil
+
+	case KindReleaseBranch:
+		base := baseRefOverride
+		if base == "" {
+			tag, err := previousReleaseTag(ctx, b.Major, b.Minor)
+			if err != nil {
+				return false, 0, nil, fmt.Errorf("find previous release tag: %w", err)
+			}
+			base = tag
+		}
+		if base == "" {
+			fmt.Fprintf(os.Stderr, "No previous release tag found for %s, running everything\n", b)
+			return true, 0, nil, nil
+		}
+		fmt.Fprintf(os.Stderr, "Release branch %s build against %s\n", b, base)
+		files, err := diffNameOnly(ctx, base, "HEAD")
+		if err != nil {
+			return false, 0, nil, fmt.Errorf("diff release branch: %w", err)
+		}
+		return false, 0, files, nil
+
+	default:
+		fmt.Fprintf(os.Stderr, "No pull request number found in branch.\n")
+		return true, 0, nil, nil
+	}
+}
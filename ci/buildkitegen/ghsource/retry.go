@@ -0,0 +1,115 @@
+package ghsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// maxAttempts bounds retries for a single page so a persistently broken API
+// can't hang pipeline generation forever.
+const maxAttempts = 5
+
+// ListFiles fetches every changed file on a pull request, retrying rate
+// limit (403 with X-RateLimit-Reset/Retry-After) and 5xx responses with
+// exponential backoff.
+func ListFiles(ctx context.Context, client *github.Client, owner, repo string, number int) ([]string, error) {
+	var changedFiles []string
+	opt := &github.ListOptions{PerPage: 100}
+
+	for {
+		files, resp, err := listFilesPage(ctx, client, owner, repo, number, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			changedFiles = append(changedFiles, *file.Filename)
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return changedFiles, nil
+}
+
+func listFilesPage(ctx context.Context, client *github.Client, owner, repo string, number int, opt *github.ListOptions) ([]*github.CommitFile, *github.Response, error) {
+	var lastErr error
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			wait := backoffFor(lastErr, attempt)
+			fmt.Fprintf(os.Stderr, "ghsource: retrying ListFiles after %v (attempt %d/%d): %v\n", wait, attempt+1, maxAttempts, lastErr)
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return nil, nil, ctx.Err()
+			}
+		}
+
+		files, resp, err := client.PullRequests.ListFiles(ctx, owner, repo, number, opt)
+		if err == nil {
+			return files, resp, nil
+		}
+		if !retryable(err) {
+			return nil, nil, err
+		}
+		lastErr = err
+	}
+
+	return nil, nil, fmt.Errorf("ListFiles: giving up after %d attempts: %w", maxAttempts, lastErr)
+}
+
+// retryable reports whether err is a transient GitHub API error worth
+// retrying: rate limiting, abuse detection, or a 5xx response.
+func retryable(err error) bool {
+	var rateLimitErr *github.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return true
+	}
+
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		return true
+	}
+
+	var ghErr *github.ErrorResponse
+	if errors.As(err, &ghErr) && ghErr.Response != nil && ghErr.Response.StatusCode >= 500 {
+		return true
+	}
+
+	return false
+}
+
+// backoffFor decides how long to wait before the next attempt, honoring
+// whatever reset/retry hint the error carries and otherwise doubling a small
+// base delay.
+func backoffFor(err error, attempt int) time.Duration {
+	var rateLimitErr *github.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		if wait := time.Until(rateLimitErr.Rate.Reset.Time); wait > 0 {
+			return wait
+		}
+	}
+
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) && abuseErr.RetryAfter != nil {
+		return *abuseErr.RetryAfter
+	}
+
+	base := 500 * time.Millisecond
+	for i := 0; i < attempt; i++ {
+		base *= 2
+	}
+	if max := 30 * time.Second; base > max {
+		base = max
+	}
+	return base
+}
@@ -0,0 +1,41 @@
+package ghsource
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+func TestRetryableRateLimitError(t *testing.T) {
+	if !retryable(&github.RateLimitError{}) {
+		t.Error("expected RateLimitError to be retryable")
+	}
+}
+
+func TestRetryableNotFound(t *testing.T) {
+	if retryable(&github.ErrorResponse{Response: nil}) {
+		t.Error("expected a plain ErrorResponse with no 5xx status not to be retryable")
+	}
+	if retryable(errors.New("boom")) {
+		t.Error("expected an unrelated error not to be retryable")
+	}
+}
+
+func TestBackoffForAbuseRetryAfter(t *testing.T) {
+	retryAfter := 3 * time.Second
+	wait := backoffFor(&github.AbuseRateLimitError{RetryAfter: &retryAfter}, 0)
+	if wait != retryAfter {
+		t.Errorf("got %v, want %v", wait, retryAfter)
+	}
+}
+
+func TestBackoffForExponential(t *testing.T) {
+	err := errors.New("boom")
+	first := backoffFor(err, 0)
+	second := backoffFor(err, 1)
+	if second <= first {
+		t.Errorf("expected backoff to grow: first=%v second=%v", first, second)
+	}
+}
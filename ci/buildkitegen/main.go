@@ -2,254 +2,445 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/anza-xyz/agave/ci/buildkitegen/config"
+	"github.com/anza-xyz/agave/ci/buildkitegen/ghsource"
+	"github.com/anza-xyz/agave/ci/buildkitegen/notify"
+	"github.com/anza-xyz/agave/ci/buildkitegen/partition"
+	"github.com/anza-xyz/agave/ci/buildkitegen/policy"
 	"github.com/buildkite/buildkite-sdk/sdk/go/sdk/buildkite"
 	"github.com/google/go-github/github"
 )
 
+// notifyOnFailLabel opts a PR build into the failure-notification step,
+// which is otherwise only emitted on push builds.
+const notifyOnFailLabel = "notify-on-fail"
+
+// historyBuilds is how many recent passed builds on master buildkitegen
+// looks at when computing a timing-driven shard assignment.
+const historyBuilds = 10
+
+// timingContext carries what buildTimingAssignment needs to ask the
+// Buildkite API for historical step timings and cache the result.
+type timingContext struct {
+	client *partition.Client
+	cache  *partition.Cache
+	commit string
+	branch string
+}
+
+func newTimingContext() *timingContext {
+	cacheDir := os.Getenv("BUILDKITEGEN_PARTITION_CACHE_DIR")
+	if cacheDir == "" {
+		cacheDir = "/tmp/buildkitegen-partition-cache"
+	}
+
+	return &timingContext{
+		client: partition.NewClient(
+			os.Getenv("BUILDKITE_ORGANIZATION_SLUG"),
+			os.Getenv("BUILDKITE_PIPELINE_SLUG"),
+			os.Getenv("BUILDKITE_API_TOKEN"),
+		),
+		cache:  &partition.Cache{Dir: cacheDir},
+		commit: os.Getenv("BUILDKITE_COMMIT"),
+		branch: "master",
+	}
+}
+
 func main() {
+	configPath := flag.String("config", "pipeline.yaml", "path to the pipeline step catalog")
+	dryRun := flag.Bool("dry-run", false, "print which steps would be selected and why, instead of emitting the pipeline")
+	notifyFlag := flag.Bool("notify", false, "post a failure summary for the current Buildkite build instead of generating a pipeline")
+	printPolicyFlag := flag.Bool("print-policy", false, "print the effective CI policy for the current build instead of generating a pipeline")
+	flag.Parse()
+
+	ctx := context.Background()
+
+	if *notifyFlag {
+		if err := runNotify(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "notify: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *printPolicyFlag {
+		if err := runPrintPolicy(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "print-policy: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cfg, err := config.LoadFile(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load pipeline config: %v\n", err)
+		os.Exit(1)
+	}
+
 	branch := os.Getenv("BUILDKITE_BRANCH")
 	if branch == "" {
 		fmt.Fprintln(os.Stderr, "BUILDKITE_BRANCH environment variable is not set.")
 		os.Exit(1)
 	}
-
 	fmt.Fprintf(os.Stderr, "Current branch: %s\n", branch)
-	isPush := true
-	prNumber := 0
-	changedFiles := []string{}
-	re := regexp.MustCompile(`pull/(\d+)/head`)
-	matches := re.FindStringSubmatch(branch)
-	var err error
-	if len(matches) > 1 {
-		prNumber, err = strconv.Atoi(matches[1])
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to convert pull request number to integer: %v\n", err)
-			os.Exit(1)
+
+	client := ghsource.NewClient(ctx)
+	isPush, prNumber, changedFiles, err := ghsource.Resolve(ctx, client, branch)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to resolve changed files: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "+++ Total changed files: %d\n", len(changedFiles))
+	for _, v := range changedFiles {
+		fmt.Fprintf(os.Stderr, "- %s\n", v)
+	}
+
+	pol, err := fetchPolicy(ctx, client, prNumber)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to resolve CI policy, ignoring trailers/labels: %v\n", err)
+		pol = policy.Policy{}
+	}
+
+	if *dryRun {
+		runDryRun(cfg, isPush, changedFiles, pol)
+		return
+	}
+
+	pipeline := buildPipeline(cfg, isPush, changedFiles, pol, newTimingContext())
+
+	if shouldNotifyOnFail(ctx, client, isPush, prNumber) {
+		pipeline.AddStep(buildkite.WaitStep{ContinueOnFailure: p(true)})
+		pipeline.AddStep(notifyStep())
+	}
+
+	output, err := pipeline.ToJSON()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(output)
+}
+
+// shouldNotifyOnFail decides whether to append the failure-notification
+// step: always on push builds, and on PR builds only when the PR carries
+// the notifyOnFailLabel label.
+func shouldNotifyOnFail(ctx context.Context, client *github.Client, isPush bool, prNumber int) bool {
+	if isPush {
+		return true
+	}
+	if prNumber == 0 {
+		return false
+	}
+
+	labels, err := ghsource.Labels(ctx, client, prNumber)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "notify: failed to fetch PR labels: %v\n", err)
+		return false
+	}
+	return ghsource.HasLabel(labels, notifyOnFailLabel)
+}
+
+// notifyStep is the final pipeline step: it runs after everything else
+// (AllowDependencyFailure so it still runs when earlier steps fail) and
+// reports any failures via `buildkitegen --notify`.
+func notifyStep() buildkite.CommandStep {
+	return buildkite.CommandStep{
+		Name:                   p("notify-on-failure"),
+		Command:                p("cd ci/buildkitegen && go run . --notify"),
+		AllowDependencyFailure: p(true),
+		TimeoutInMinutes:       p(int64(5)),
+		Agents: map[string]any{
+			"queue": "check",
+		},
+	}
+}
+
+// runNotify fetches the current Buildkite build, summarizes its failed
+// steps (including failing Rust test names pulled from uploaded JUnit
+// reports), and sends the summary to every configured sink.
+func runNotify(ctx context.Context) error {
+	buildNumber, err := strconv.Atoi(os.Getenv("BUILDKITE_BUILD_NUMBER"))
+	if err != nil {
+		return fmt.Errorf("BUILDKITE_BUILD_NUMBER: %w", err)
+	}
+
+	client := partition.NewClient(
+		os.Getenv("BUILDKITE_ORGANIZATION_SLUG"),
+		os.Getenv("BUILDKITE_PIPELINE_SLUG"),
+		os.Getenv("BUILDKITE_API_TOKEN"),
+	)
+
+	build, err := client.GetBuild(ctx, buildNumber)
+	if err != nil {
+		return err
+	}
+
+	report, err := notify.BuildReport(ctx, client, os.Getenv("BUILDKITE_BUILD_URL"), build)
+	if err != nil {
+		return err
+	}
+
+	if len(report.FailedJobs) == 0 {
+		fmt.Println("notify: no failed jobs, nothing to report")
+		return nil
+	}
+
+	markdown := notify.RenderMarkdown(report)
+	for _, sink := range notifySinks(ctx) {
+		if err := sink.Send(ctx, report, markdown); err != nil {
+			fmt.Fprintf(os.Stderr, "notify: sink failed: %v\n", err)
 		}
-		fmt.Fprintf(os.Stderr, "Extracted pull request number: %d\n", prNumber)
-
-		isPush = false
-
-		client := github.NewClient(nil)
-		opt := &github.ListOptions{PerPage: 100}
-		for {
-			files, resp, err := client.PullRequests.ListFiles(
-				context.Background(),
-				"anza-xyz",
-				"agave",
-				prNumber,
-				opt,
-			)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to list changed files: %v\n", err)
-				os.Exit(1)
-			}
+	}
+	return nil
+}
+
+// notifySinks assembles the sinks to report to, based on which sink-specific
+// env vars are set. The Buildkite annotation sink always runs.
+func notifySinks(ctx context.Context) []notify.Sink {
+	sinks := []notify.Sink{notify.BuildkiteAnnotationSink{}}
+
+	if os.Getenv("BUILDKITEGEN_NOTIFY_GITHUB_CHECK") == "true" {
+		sinks = append(sinks, notify.GitHubCheckSink{
+			Client: ghsource.NewClient(ctx),
+			Owner:  ghsource.Owner,
+			Repo:   ghsource.Repo,
+			SHA:    os.Getenv("BUILDKITE_COMMIT"),
+		})
+	}
+
+	if webhookURL := os.Getenv("SLACK_WEBHOOK_URL"); webhookURL != "" {
+		sinks = append(sinks, notify.SlackWebhookSink{WebhookURL: webhookURL})
+	}
 
-			for _, file := range files {
-				changedFiles = append(changedFiles, *file.Filename)
+	return sinks
+}
+
+// buildPipeline walks the step catalog in order, emitting a CommandStep (or
+// WaitStep) for each entry that config.Selected and pol.Resolve pick for
+// this build. An initial annotate step always runs first, showing reviewers
+// which CI-Skip/CI-Force/CI-Only/ci-* overrides are in effect.
+func buildPipeline(cfg *config.Config, isPush bool, changedFiles []string, pol policy.Policy, tc *timingContext) buildkite.Pipeline {
+	pipeline := buildkite.Pipeline{}
+	pipeline.AddStep(printPolicyStep())
+
+	for _, step := range cfg.Steps {
+		if step.Wait {
+			pipeline.AddStep(buildkite.WaitStep{})
+			continue
+		}
+
+		baseSelected, _ := cfg.Selected(step, isPush, changedFiles)
+		selected, _ := pol.Resolve(step.Name, step.AlwaysRun, baseSelected)
+		if !selected {
+			continue
+		}
+
+		if step.Parallelism > 1 {
+			if step.TimingDriven {
+				if shardCommands, ok := timingDrivenShards(step, tc); ok {
+					for i, command := range shardCommands {
+						name := fmt.Sprintf("%s %d/%d", step.Name, i+1, len(shardCommands))
+						pipeline.AddStep(commandStep(step, name, command))
+					}
+					continue
+				}
+				fmt.Fprintf(os.Stderr, "partition: no usable timing data for %q, falling back to modulo split\n", step.Name)
 			}
 
-			if resp.NextPage == 0 {
-				break
+			for i := 0; i < step.Parallelism; i++ {
+				pipeline.AddStep(commandStepForShard(step, i))
 			}
-			opt.Page = resp.NextPage
+			continue
 		}
 
-		fmt.Fprintf(os.Stderr, "+++ Total changed files: %d\n", len(changedFiles))
-		for _, v := range changedFiles {
-			fmt.Fprintf(os.Stderr, "- %s\n", v)
-		}
-	} else {
-		fmt.Fprintf(os.Stderr, "No pull request number found in branch.\n")
-		isPush = true
+		pipeline.AddStep(commandStep(step, step.Name, step.Command))
 	}
-	pipeline := buildkite.Pipeline{}
 
-	// sanity
-	pipeline.AddStep(buildkite.CommandStep{
-		Name:             p("sanity"),
-		Command:          p("ci/test-sanity.sh"),
-		TimeoutInMinutes: p(int64(10)),
+	return pipeline
+}
+
+// timingDrivenShards returns the per-shard commands for step computed from
+// historical test timings, or ok=false if no usable assignment could be
+// produced (e.g. the Buildkite API/cache has nothing fresh enough), in which
+// case the caller should fall back to modulo splitting.
+func timingDrivenShards(step config.StepDef, tc *timingContext) (commands []string, ok bool) {
+	if tc.commit == "" {
+		return nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	assignment, ok := partition.Plan(ctx, tc.client, tc.cache, tc.commit, partition.Options{
+		Step:          step.Name,
+		Shards:        step.Parallelism,
+		HistoryBuilds: historyBuilds,
+		Branch:        tc.branch,
+		ArtifactPath:  step.ArtifactPath,
+	})
+	if !ok {
+		return nil, false
+	}
+
+	commands = make([]string, len(assignment.Shards))
+	for i, tests := range assignment.Shards {
+		commands[i] = fmt.Sprintf(step.TestListCommand, strings.Join(tests, ","))
+	}
+	return commands, true
+}
+
+func commandStepForShard(step config.StepDef, i int) buildkite.CommandStep {
+	shardIndex := i + 1
+	cmdIndex := shardIndex
+	if step.ZeroIndexedShard {
+		cmdIndex = i
+	}
+	name := fmt.Sprintf("%s %d/%d", step.Name, shardIndex, step.Parallelism)
+	command := fmt.Sprintf(step.Command, cmdIndex, step.Parallelism)
+	return commandStep(step, name, command)
+}
+
+func commandStep(step config.StepDef, name, command string) buildkite.CommandStep {
+	cs := buildkite.CommandStep{
+		Name:             p(name),
+		Command:          p(command),
+		TimeoutInMinutes: p(step.TimeoutInMinutes),
 		Agents: map[string]any{
-			"queue": "check",
+			"queue": step.Queue,
 		},
-	})
-	pipeline.AddStep(buildkite.WaitStep{})
-
-	// shellcheck
-	if isPush || check(changedFiles, func(v string) bool {
-		return false ||
-			strings.HasPrefix(v, "ci/buildkitegen") ||
-			strings.HasSuffix(v, ".sh")
-	}) {
-		pipeline.AddStep(buildkite.CommandStep{
-			Name:             p("shellcheck"),
-			Command:          p("ci/shellcheck.sh"),
-			TimeoutInMinutes: p(int64(5)),
-			Agents: map[string]any{
-				"queue": "check",
-			},
-		})
-		pipeline.AddStep(buildkite.WaitStep{})
-	}
-
-	// check
-	if isPush || check(changedFiles, func(v string) bool {
-		return false ||
-			strings.HasPrefix(v, "ci/buildkitegen") ||
-			strings.HasSuffix(v, ".rs") ||
-			strings.HasSuffix(v, ".Cargo.toml") ||
-			strings.HasSuffix(v, ".Cargo.lock")
-	}) {
-		pipeline.AddStep(buildkite.CommandStep{
-			Name:             p("check"),
-			Command:          p("ci/docker-run-default-image.sh ci/test-checks.sh"),
-			TimeoutInMinutes: p(int64(20)),
-			Agents: map[string]any{
-				"queue": "check",
-			},
-		})
+	}
 
-		dcouParallel := 3
-		for i := 1; i <= dcouParallel; i++ {
-			pipeline.AddStep(buildkite.CommandStep{
-				Name:             p(fmt.Sprintf("dcou %d/%d", i, dcouParallel)),
-				Command:          p(fmt.Sprintf("ci/docker-run-default-image.sh ci/test-dev-context-only-utils.sh --partition %d/%d", i, dcouParallel)),
-				TimeoutInMinutes: p(int64(20)),
-				Agents: map[string]any{
-					"queue": "check",
-				},
-			})
+	if step.Retry != nil {
+		cs.Retry = &buildkite.RetryComplex{
+			Automatic: []buildkite.RetryComplexAutomatic{
+				{Limit: p(step.Retry.Limit)},
+			},
 		}
+	}
 
-		pipeline.AddStep(buildkite.CommandStep{
-			Name:             p("miri"),
-			Command:          p("ci/docker-run-default-image.sh ci/test-miri.sh"),
-			TimeoutInMinutes: p(int64(5)),
-			Agents: map[string]any{
-				"queue": "check",
-			},
-		})
+	return cs
+}
 
-		pipeline.AddStep(buildkite.CommandStep{
-			Name:             p("frozen-abi"),
-			Command:          p("ci/docker-run-default-image.sh ./test-abi.sh"),
-			TimeoutInMinutes: p(int64(15)),
-			Agents: map[string]any{
-				"queue": "check",
-			},
-		})
-		pipeline.AddStep(buildkite.WaitStep{})
-
-		// partition
-		partitionParallel := 3
-		for i := 0; i < partitionParallel; i++ {
-			pipeline.AddStep(buildkite.CommandStep{
-				Name:             p(fmt.Sprintf("partition %d/%d", i+1, partitionParallel)),
-				Command:          p(fmt.Sprintf("ci/docker-run-default-image.sh ci/stable/run-partition.sh %d %d", i, partitionParallel)),
-				TimeoutInMinutes: p(int64(15)),
-				Retry: &buildkite.RetryComplex{
-					Automatic: []buildkite.RetryComplexAutomatic{
-						{
-							Limit: p(int64(3)),
-						},
-					},
-				},
-				Agents: map[string]any{
-					"queue": "solana",
-				},
-			})
+// runDryRun prints, for every step in the catalog, whether it was selected
+// and which glob pattern/changed file (or policy override) made it match.
+func runDryRun(cfg *config.Config, isPush bool, changedFiles []string, pol policy.Policy) {
+	fmt.Printf("dry-run: isPush=%v changedFiles=%d\n", isPush, len(changedFiles))
+	fmt.Println(policy.RenderMarkdown(pol))
+
+	for _, step := range cfg.Steps {
+		if step.Wait {
+			fmt.Println("  ---- wait ----")
+			continue
 		}
 
-		// local cluster
-		localClusterParallel := 10
-		for i := 0; i < localClusterParallel; i++ {
-			pipeline.AddStep(buildkite.CommandStep{
-				Name:             p(fmt.Sprintf("local-cluster %d/%d", i+1, localClusterParallel)),
-				Command:          p(fmt.Sprintf("ci/docker-run-default-image.sh ci/stable/run-local-cluster-partially.sh %d %d", i, localClusterParallel)),
-				TimeoutInMinutes: p(int64(30)),
-				Agents: map[string]any{
-					"queue": "solana",
-				},
-			})
+		baseSelected, match := cfg.Selected(step, isPush, changedFiles)
+		selected, reason := pol.Resolve(step.Name, step.AlwaysRun, baseSelected)
+		if !selected {
+			fmt.Printf("  [skip]   %-12s paths=%s reason=%s\n", step.Name, step.Paths, reason)
+			continue
 		}
 
-		// localnet
-		pipeline.AddStep(buildkite.CommandStep{
-			Name:             p("localnet"),
-			Command:          p("ci/docker-run-default-image.sh ci/stable/run-localnet.sh"),
-			TimeoutInMinutes: p(int64(30)),
-			Agents: map[string]any{
-				"queue": "solana",
-			},
-		})
+		switch {
+		case reason != "path-based selection":
+			fmt.Printf("  [select] %-12s reason=%s\n", step.Name, reason)
+		case isPush:
+			fmt.Printf("  [select] %-12s reason=push build\n", step.Name)
+		case step.AlwaysRun:
+			fmt.Printf("  [select] %-12s reason=always_run\n", step.Name)
+		default:
+			fmt.Printf("  [select] %-12s reason=%q matched %q\n", step.Name, match.Pattern, match.File)
+		}
+	}
+}
 
-		// docs test
-		pipeline.AddStep(buildkite.CommandStep{
-			Name:             p("docstest"),
-			Command:          p("ci/docker-run-default-image.sh ci/test-docs.sh"),
-			TimeoutInMinutes: p(int64(15)),
-			Agents: map[string]any{
-				"queue": "solana",
-			},
-		})
-		pipeline.AddStep(buildkite.WaitStep{})
-
-		// stable sbf
-		pipeline.AddStep(buildkite.CommandStep{
-			Name:             p("stable-sbf"),
-			Command:          p("ci/docker-run-default-image.sh ci/test-stable-sbf.sh"),
-			TimeoutInMinutes: p(int64(35)),
-			Agents: map[string]any{
-				"queue": "solana",
-			},
-		})
+// fetchPolicy gathers the labels, and (for trusted authors only) the
+// PR-body and commit-message trailers, for prNumber into a single effective
+// Policy. Push and release-branch builds (prNumber == 0) always resolve to
+// the empty Policy.
+//
+// Trailers are free text anyone opening a PR can write, so they're only
+// honored from authors with write access to the repo (AuthorAssociation
+// OWNER/MEMBER/COLLABORATOR) — the same people who could apply a ci-*
+// label directly. An external contributor's PR body/commits are parsed for
+// visibility (e.g. --print-policy can still explain why a trailer had no
+// effect) but never change selection.
+func fetchPolicy(ctx context.Context, client *github.Client, prNumber int) (policy.Policy, error) {
+	if prNumber == 0 {
+		return policy.Policy{}, nil
+	}
 
-		// shuttle tests
-		pipeline.AddStep(buildkite.CommandStep{
-			Name:             p("shuttle"),
-			Command:          p("ci/docker-run-default-image.sh ci/test-shuttle.sh"),
-			TimeoutInMinutes: p(int64(10)),
-			Agents: map[string]any{
-				"queue": "solana",
-			},
-		})
+	pr, _, err := client.PullRequests.Get(ctx, ghsource.Owner, ghsource.Repo, prNumber)
+	if err != nil {
+		return policy.Policy{}, fmt.Errorf("get PR #%d: %w", prNumber, err)
+	}
 
-		// coverage
-		pipeline.AddStep(buildkite.CommandStep{
-			Name:             p("coverage"),
-			Command:          p("ci/docker-run-default-image.sh ci/test-coverage.sh"),
-			TimeoutInMinutes: p(int64(80)),
-			Agents: map[string]any{
-				"queue": "solana",
-			},
-		})
+	var pol policy.Policy
+	if ghsource.IsTrustedAuthor(pr.GetAuthorAssociation()) {
+		pol = policy.ParseTrailers(pr.GetBody())
+
+		commits, _, err := client.PullRequests.ListCommits(ctx, ghsource.Owner, ghsource.Repo, prNumber, &github.ListOptions{PerPage: 100})
+		if err != nil {
+			return policy.Policy{}, fmt.Errorf("list commits for PR #%d: %w", prNumber, err)
+		}
+		for _, c := range commits {
+			if c.Commit != nil {
+				pol = pol.Merge(policy.ParseTrailers(c.Commit.GetMessage()))
+			}
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "policy: ignoring CI-Skip/CI-Force/CI-Only trailers from untrusted author_association %q on PR #%d\n", pr.GetAuthorAssociation(), prNumber)
 	}
 
-	// print pipeline
-	output, err := pipeline.ToJSON()
+	labels, err := ghsource.Labels(ctx, client, prNumber)
 	if err != nil {
-		log.Fatal(err)
+		return policy.Policy{}, fmt.Errorf("list labels for PR #%d: %w", prNumber, err)
 	}
-	fmt.Println(output)
+	pol = pol.Merge(policy.ParseLabels(labels))
+
+	return pol, nil
 }
 
-func p[T any](v T) *T {
-	return &v
+// runPrintPolicy re-resolves the current build's branch and CI policy and
+// prints its Markdown rendering to stdout, for printPolicyStep to pipe into
+// `buildkite-agent annotate`.
+func runPrintPolicy(ctx context.Context) error {
+	client := ghsource.NewClient(ctx)
+	_, prNumber, _, err := ghsource.Resolve(ctx, client, os.Getenv("BUILDKITE_BRANCH"))
+	if err != nil {
+		return err
+	}
+
+	pol, err := fetchPolicy(ctx, client, prNumber)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(policy.RenderMarkdown(pol))
+	return nil
 }
 
-func check(s []string, f func(string) bool) bool {
-	for _, v := range s {
-		if f(v) {
-			return true
-		}
+// printPolicyStep is the first step of every generated pipeline: it shows
+// reviewers which CI-Skip/CI-Force/CI-Only trailers or ci-* labels changed
+// step selection for this build.
+func printPolicyStep() buildkite.CommandStep {
+	return buildkite.CommandStep{
+		Name:             p("ci-policy"),
+		Command:          p("cd ci/buildkitegen && go run . --print-policy | buildkite-agent annotate --style info --context ci-policy"),
+		TimeoutInMinutes: p(int64(5)),
+		Agents: map[string]any{
+			"queue": "check",
+		},
 	}
-	return false
+}
+
+func p[T any](v T) *T {
+	return &v
 }
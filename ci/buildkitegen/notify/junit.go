@@ -0,0 +1,55 @@
+package notify
+
+import "encoding/xml"
+
+// junitCase is the subset of a JUnit <testcase> needed to tell whether it
+// failed, distinct from partition.ParseJUnit which only cares about
+// durations.
+type junitCase struct {
+	Name      string    `xml:"name,attr"`
+	ClassName string    `xml:"classname,attr"`
+	Failure   *struct{} `xml:"failure"`
+	Error     *struct{} `xml:"error"`
+}
+
+type junitSuite struct {
+	TestCases []junitCase `xml:"testcase"`
+}
+
+type junitSuites struct {
+	TestSuites []junitSuite `xml:"testsuite"`
+}
+
+// failedTestNames extracts the names of failed/errored test cases from a
+// JUnit report, accepting both a bare <testsuite> root and a <testsuites>
+// wrapper.
+func failedTestNames(data []byte) ([]string, error) {
+	var cases []junitCase
+
+	var suites junitSuites
+	if err := xml.Unmarshal(data, &suites); err == nil && len(suites.TestSuites) > 0 {
+		for _, s := range suites.TestSuites {
+			cases = append(cases, s.TestCases...)
+		}
+	} else {
+		var suite junitSuite
+		if err := xml.Unmarshal(data, &suite); err != nil {
+			return nil, err
+		}
+		cases = suite.TestCases
+	}
+
+	var names []string
+	for _, c := range cases {
+		if c.Failure == nil && c.Error == nil {
+			continue
+		}
+		name := c.Name
+		if c.ClassName != "" {
+			name = c.ClassName + "::" + c.Name
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
@@ -0,0 +1,32 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RenderMarkdown renders a Report as the Markdown body posted to every
+// configured sink: a heading, then one bullet per failed step linking to
+// its job log, with a collapsible list of failing Rust test names when any
+// were recovered from the job's JUnit report.
+func RenderMarkdown(r *Report) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "### :rotating_light: %d step(s) failed\n\n", len(r.FailedJobs))
+
+	for _, job := range r.FailedJobs {
+		fmt.Fprintf(&b, "- [%s](%s)\n", job.Name, job.WebURL)
+
+		if len(job.FailedTests) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&b, "  <details><summary>%d failing test(s)</summary>\n\n", len(job.FailedTests))
+		for _, name := range job.FailedTests {
+			fmt.Fprintf(&b, "  - `%s`\n", name)
+		}
+		b.WriteString("  </details>\n")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,74 @@
+// Package notify builds and sends a summary of a Buildkite build's failed
+// steps to pluggable sinks (a Buildkite annotation, a GitHub check run,
+// and/or a Slack webhook).
+package notify
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/anza-xyz/agave/ci/buildkitegen/partition"
+)
+
+// junitArtifactGlob matches the JUnit reports buildkitegen's Rust test steps
+// upload, regardless of which shard produced them.
+const junitArtifactGlob = "junit-*.xml"
+
+// FailedJob is one failed step in the build, with whatever failing Rust
+// test names could be recovered from its uploaded JUnit report.
+type FailedJob struct {
+	Name        string
+	WebURL      string
+	FailedTests []string
+}
+
+// Report is everything RenderMarkdown needs to describe a build's failures.
+type Report struct {
+	BuildNumber int
+	BuildURL    string
+	FailedJobs  []FailedJob
+}
+
+// BuildReport inspects build's jobs, downloading and parsing the JUnit
+// report for each failed job to recover individual failing test names.
+func BuildReport(ctx context.Context, client *partition.Client, buildURL string, build *partition.Build) (*Report, error) {
+	report := &Report{
+		BuildNumber: build.Number,
+		BuildURL:    buildURL,
+	}
+
+	for _, job := range build.Jobs {
+		if job.State != "failed" {
+			continue
+		}
+
+		failedJob := FailedJob{Name: job.Name, WebURL: job.WebURL}
+
+		artifacts, err := client.JobArtifacts(ctx, build.Number, job.ID)
+		if err != nil {
+			return nil, fmt.Errorf("list artifacts for job %s: %w", job.Name, err)
+		}
+
+		for _, a := range artifacts {
+			if ok, _ := filepath.Match(junitArtifactGlob, filepath.Base(a.Path)); !ok {
+				continue
+			}
+
+			data, err := client.DownloadArtifact(ctx, a)
+			if err != nil {
+				continue
+			}
+
+			names, err := failedTestNames(data)
+			if err != nil {
+				continue
+			}
+			failedJob.FailedTests = append(failedJob.FailedTests, names...)
+		}
+
+		report.FailedJobs = append(report.FailedJobs, failedJob)
+	}
+
+	return report, nil
+}
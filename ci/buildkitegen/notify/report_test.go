@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anza-xyz/agave/ci/buildkitegen/partition"
+)
+
+func TestBuildReportAndRenderMarkdown(t *testing.T) {
+	const junit = `<testsuites>
+  <testsuite name="partition">
+    <testcase classname="runtime::tests" name="test_flaky" time="1.2"><failure message="assertion failed"/></testcase>
+    <testcase classname="runtime::tests" name="test_ok" time="0.5"/>
+  </testsuite>
+</testsuites>`
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/junit", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(junit))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("/organizations/acme/pipelines/agave/builds/99/jobs/job-failed/artifacts", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]partition.Artifact{
+			{ID: "art-1", Path: "junit-partition-2.xml", URL: srv.URL + "/junit"},
+		})
+	})
+
+	build := partition.Build{
+		Number: 99,
+		State:  "failed",
+		Jobs: []partition.Job{
+			{ID: "job-failed", Name: "partition 2/3", State: "failed", WebURL: "https://buildkite.com/acme/agave/builds/99#job-failed"},
+			{ID: "job-passed", Name: "partition 1/3", State: "passed", WebURL: "https://buildkite.com/acme/agave/builds/99#job-passed"},
+		},
+	}
+
+	client := partition.NewClientWithBaseURL(srv.URL, "acme", "agave", "test-token")
+
+	report, err := BuildReport(context.Background(), client, "https://buildkite.com/acme/agave/builds/99", &build)
+	if err != nil {
+		t.Fatalf("BuildReport: %v", err)
+	}
+
+	if len(report.FailedJobs) != 1 {
+		t.Fatalf("got %d failed jobs, want 1", len(report.FailedJobs))
+	}
+	failed := report.FailedJobs[0]
+	if failed.Name != "partition 2/3" {
+		t.Errorf("got job name %q", failed.Name)
+	}
+	if len(failed.FailedTests) != 1 || failed.FailedTests[0] != "runtime::tests::test_flaky" {
+		t.Fatalf("got failed tests %v", failed.FailedTests)
+	}
+
+	markdown := RenderMarkdown(report)
+	for _, want := range []string{
+		"1 step(s) failed",
+		"[partition 2/3](https://buildkite.com/acme/agave/builds/99#job-failed)",
+		"1 failing test(s)",
+		"`runtime::tests::test_flaky`",
+	} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("markdown missing %q, got:\n%s", want, markdown)
+		}
+	}
+	if strings.Contains(markdown, "test_ok") {
+		t.Errorf("markdown should not mention the passing test:\n%s", markdown)
+	}
+}
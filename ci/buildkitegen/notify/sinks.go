@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+
+	"github.com/google/go-github/github"
+)
+
+// Sink delivers a rendered failure report somewhere.
+type Sink interface {
+	Send(ctx context.Context, report *Report, markdown string) error
+}
+
+// BuildkiteAnnotationSink posts markdown as a Buildkite build annotation via
+// `buildkite-agent annotate`, run from within the notify CommandStep itself.
+type BuildkiteAnnotationSink struct{}
+
+func (BuildkiteAnnotationSink) Send(ctx context.Context, _ *Report, markdown string) error {
+	cmd := exec.CommandContext(ctx, "buildkite-agent", "annotate", markdown,
+		"--style", "error",
+		"--context", "ci-failure-summary",
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("buildkite-agent annotate: %w: %s", err, string(out))
+	}
+	return nil
+}
+
+// GitHubCheckSink reports the failure summary as a GitHub Check Run on SHA.
+type GitHubCheckSink struct {
+	Client *github.Client
+	Owner  string
+	Repo   string
+	SHA    string
+}
+
+func (s GitHubCheckSink) Send(ctx context.Context, report *Report, markdown string) error {
+	conclusion := "failure"
+	title := fmt.Sprintf("%d step(s) failed", len(report.FailedJobs))
+
+	_, _, err := s.Client.Checks.CreateCheckRun(ctx, s.Owner, s.Repo, github.CreateCheckRunOptions{
+		Name:       "ci-failure-summary",
+		HeadSHA:    s.SHA,
+		Conclusion: &conclusion,
+		DetailsURL: &report.BuildURL,
+		Output: &github.CheckRunOutput{
+			Title:   &title,
+			Summary: &markdown,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("create check run: %w", err)
+	}
+	return nil
+}
+
+// SlackWebhookSink posts markdown to a Slack incoming webhook.
+type SlackWebhookSink struct {
+	WebhookURL string
+	HTTPClient *http.Client
+}
+
+func (s SlackWebhookSink) Send(ctx context.Context, _ *Report, markdown string) error {
+	client := s.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	body, err := json.Marshal(map[string]string{"text": markdown})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post to slack webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack webhook returned status %s", resp.Status)
+	}
+	return nil
+}
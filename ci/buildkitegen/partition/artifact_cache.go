@@ -0,0 +1,34 @@
+package partition
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+// commitSearchHistory bounds how many recent passed builds Plan scans
+// looking for one built from the same commit as the one it's planning for.
+const commitSearchHistory = 20
+
+// firstBuildWithCommit returns a pointer to the first build in builds whose
+// Commit matches commit, or nil if none do.
+func firstBuildWithCommit(builds []Build, commit string) *Build {
+	for i := range builds {
+		if builds[i].Commit == commit {
+			return &builds[i]
+		}
+	}
+	return nil
+}
+
+// uploadArtifact uploads the cache file at path as a Buildkite artifact, run
+// from within the job itself (buildkite-agent needs the job's own
+// credentials), so a later build for the same commit can download it
+// instead of recomputing the assignment.
+func uploadArtifact(ctx context.Context, path string) error {
+	cmd := exec.CommandContext(ctx, "buildkite-agent", "artifact", "upload", path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("buildkite-agent artifact upload %s: %w: %s", path, err, string(out))
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package partition
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Assignment is the bin-packed shard plan for one step (e.g. "partition" or
+// "local-cluster") at a given commit. It is marshalled as JSON and cached so
+// that re-running buildkitegen for the same commit (e.g. a pipeline rebuild)
+// does not need to re-fetch and re-bin-pack the historical timings.
+type Assignment struct {
+	Commit      string     `json:"commit"`
+	Step        string     `json:"step"`
+	Shards      [][]string `json:"shards"`
+	GeneratedAt time.Time  `json:"generated_at"`
+}
+
+// Stale reports whether the assignment is older than maxAge and should no
+// longer be trusted.
+func (a *Assignment) Stale(maxAge time.Duration) bool {
+	return time.Since(a.GeneratedAt) > maxAge
+}
+
+// Cache persists Assignments to disk, keyed by commit, step, and shard
+// count (a pipeline.yaml edit that reparallelizes a step must not reuse an
+// assignment binned for the old shard count).
+//
+// Dir itself is local to whichever agent runs buildkitegen, which is not
+// durable across builds on ephemeral agents. Plan compensates by also
+// uploading each cache file as a Buildkite artifact (see uploadArtifact)
+// and, on a later build for the same commit, downloading a prior build's
+// matching artifact (see syncCacheFromPriorBuild) instead of recomputing.
+type Cache struct {
+	Dir string
+}
+
+// cacheFileName is the filename (no directory) a given commit/step/shards
+// cache entry is stored/uploaded under.
+func cacheFileName(commit, step string, shards int) string {
+	return fmt.Sprintf("%s-%s-%d.json", commit, step, shards)
+}
+
+func (c *Cache) path(commit, step string, shards int) string {
+	return filepath.Join(c.Dir, cacheFileName(commit, step, shards))
+}
+
+// Load returns the cached Assignment for commit/step/shards, if any. A
+// cache entry whose shard count doesn't match (which the keyed filename
+// should already prevent) is treated as a miss rather than trusted as-is.
+func (c *Cache) Load(commit, step string, shards int) (*Assignment, error) {
+	data, err := os.ReadFile(c.path(commit, step, shards))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var a Assignment
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("parse cached assignment: %w", err)
+	}
+	if len(a.Shards) != shards {
+		return nil, nil
+	}
+	return &a, nil
+}
+
+// Save writes an Assignment to the cache.
+func (c *Cache) Save(a *Assignment) error {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(c.path(a.Commit, a.Step, len(a.Shards)), data, 0o644)
+}
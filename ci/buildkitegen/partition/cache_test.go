@@ -0,0 +1,72 @@
+package partition
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheRoundTrip(t *testing.T) {
+	cache := &Cache{Dir: t.TempDir()}
+
+	got, err := cache.Load("abc123", "partition", 2)
+	if err != nil {
+		t.Fatalf("Load on empty cache: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("expected no cached assignment, got %+v", got)
+	}
+
+	want := &Assignment{
+		Commit:      "abc123",
+		Step:        "partition",
+		GeneratedAt: time.Now(),
+		Shards:      [][]string{{"test_a"}, {"test_b", "test_c"}},
+	}
+	if err := cache.Save(want); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	got, err = cache.Load("abc123", "partition", 2)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got == nil {
+		t.Fatal("expected cached assignment after Save")
+	}
+	if len(got.Shards) != 2 {
+		t.Fatalf("got %d shards, want 2", len(got.Shards))
+	}
+}
+
+func TestCacheLoadMissesOnShardCountChange(t *testing.T) {
+	cache := &Cache{Dir: t.TempDir()}
+
+	if err := cache.Save(&Assignment{
+		Commit:      "abc123",
+		Step:        "partition",
+		GeneratedAt: time.Now(),
+		Shards:      [][]string{{"test_a"}, {"test_b"}},
+	}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	got, err := cache.Load("abc123", "partition", 3)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("expected cache miss after pipeline.yaml reparallelized the step, got %+v", got)
+	}
+}
+
+func TestAssignmentStale(t *testing.T) {
+	a := &Assignment{GeneratedAt: time.Now().Add(-8 * 24 * time.Hour)}
+	if !a.Stale(7 * 24 * time.Hour) {
+		t.Error("expected 8-day-old assignment to be stale with a 7-day max age")
+	}
+
+	fresh := &Assignment{GeneratedAt: time.Now()}
+	if fresh.Stale(7 * 24 * time.Hour) {
+		t.Error("expected fresh assignment not to be stale")
+	}
+}
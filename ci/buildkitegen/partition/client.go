@@ -0,0 +1,178 @@
+package partition
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const apiBaseURL = "https://api.buildkite.com/v2"
+
+// Client is a minimal Buildkite REST API client covering the endpoints
+// buildkitegen needs to look up recent green builds and their step timing
+// artifacts. It is not a general-purpose Buildkite SDK.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+	org        string
+	pipeline   string
+
+	// getCache memoizes get() responses by URL for the lifetime of the
+	// Client. buildkitegen calls Plan once per timing-driven step in a
+	// single-threaded loop (main.go's buildPipeline), and every step asks
+	// the same "recent builds on branch" and, while searching for a prior
+	// build's cache artifact, the same "this job's artifacts" questions;
+	// without this a pipeline with N timing-driven steps would repeat each
+	// of those N times over.
+	getCache map[string][]byte
+}
+
+// NewClient builds a Client for the given org/pipeline. token is the
+// Buildkite API access token (BUILDKITE_API_TOKEN); it is sent as a bearer
+// token on every request.
+func NewClient(org, pipeline, token string) *Client {
+	return NewClientWithBaseURL(apiBaseURL, org, pipeline, token)
+}
+
+// NewClientWithBaseURL is like NewClient but targets a non-default API base
+// URL, for pointing a Client at a test server.
+func NewClientWithBaseURL(baseURL, org, pipeline, token string) *Client {
+	return &Client{
+		httpClient: http.DefaultClient,
+		baseURL:    baseURL,
+		token:      token,
+		org:        org,
+		pipeline:   pipeline,
+		getCache:   map[string][]byte{},
+	}
+}
+
+// Build is the subset of the Buildkite build resource buildkitegen needs.
+type Build struct {
+	Number int    `json:"number"`
+	Commit string `json:"commit"`
+	State  string `json:"state"`
+	Jobs   []Job  `json:"jobs"`
+}
+
+// Job is one step execution within a Build.
+type Job struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	StepKey string `json:"step_key"`
+	State   string `json:"state"`
+	WebURL  string `json:"web_url"`
+}
+
+// GetBuild fetches a single build by number, including its jobs.
+func (c *Client) GetBuild(ctx context.Context, buildNumber int) (*Build, error) {
+	url := fmt.Sprintf("%s/organizations/%s/pipelines/%s/builds/%d",
+		c.baseURL, c.org, c.pipeline, buildNumber)
+
+	var build Build
+	if err := c.get(ctx, url, &build); err != nil {
+		return nil, fmt.Errorf("get build %d: %w", buildNumber, err)
+	}
+	return &build, nil
+}
+
+// RecentPassedBuilds returns up to n builds on branch that finished in the
+// "passed" state, most recent first.
+func (c *Client) RecentPassedBuilds(ctx context.Context, branch string, n int) ([]Build, error) {
+	url := fmt.Sprintf("%s/organizations/%s/pipelines/%s/builds?branch=%s&state=passed&per_page=%d",
+		c.baseURL, c.org, c.pipeline, branch, n)
+
+	var builds []Build
+	if err := c.get(ctx, url, &builds); err != nil {
+		return nil, fmt.Errorf("list passed builds: %w", err)
+	}
+	if len(builds) > n {
+		builds = builds[:n]
+	}
+	return builds, nil
+}
+
+// Artifact is the subset of the Buildkite artifact resource needed to
+// download a job's JUnit report.
+type Artifact struct {
+	ID       string `json:"id"`
+	Path     string `json:"path"`
+	URL      string `json:"url"`
+	JobID    string `json:"job_id"`
+	FileName string `json:"filename"`
+}
+
+// JobArtifacts lists the artifacts uploaded by a job.
+func (c *Client) JobArtifacts(ctx context.Context, buildNumber int, jobID string) ([]Artifact, error) {
+	url := fmt.Sprintf("%s/organizations/%s/pipelines/%s/builds/%d/jobs/%s/artifacts",
+		c.baseURL, c.org, c.pipeline, buildNumber, jobID)
+
+	var artifacts []Artifact
+	if err := c.get(ctx, url, &artifacts); err != nil {
+		return nil, fmt.Errorf("list job artifacts: %w", err)
+	}
+	return artifacts, nil
+}
+
+// DownloadArtifact fetches the contents of a single artifact.
+func (c *Client) DownloadArtifact(ctx context.Context, a Artifact) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.authorize(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download artifact %s: %w", a.Path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download artifact %s: unexpected status %s", a.Path, resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+func (c *Client) get(ctx context.Context, url string, out any) error {
+	if body, ok := c.getCache[url]; ok {
+		return json.Unmarshal(body, out)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	c.authorize(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, string(body))
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return err
+	}
+	c.getCache[url] = body
+	return nil
+}
+
+func (c *Client) authorize(req *http.Request) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+}
@@ -0,0 +1,109 @@
+package partition
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientGetBuild(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/organizations/acme/pipelines/agave/builds/42" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Fatalf("missing/incorrect auth header: %q", r.Header.Get("Authorization"))
+		}
+		json.NewEncoder(w).Encode(Build{
+			Number: 42,
+			State:  "failed",
+			Jobs: []Job{
+				{ID: "job-1", Name: "partition 1/3", State: "failed", WebURL: "https://buildkite.com/acme/agave/builds/42#job-1"},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	client := NewClientWithBaseURL(srv.URL, "acme", "agave", "test-token")
+	build, err := client.GetBuild(context.Background(), 42)
+	if err != nil {
+		t.Fatalf("GetBuild: %v", err)
+	}
+	if build.Number != 42 || len(build.Jobs) != 1 || build.Jobs[0].Name != "partition 1/3" {
+		t.Fatalf("got %+v", build)
+	}
+}
+
+func TestClientJobArtifactsAndDownload(t *testing.T) {
+	const junit = `<testsuite><testcase name="test_one" time="1"/></testsuite>`
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/organizations/acme/pipelines/agave/builds/42/jobs/job-1/artifacts":
+			json.NewEncoder(w).Encode([]Artifact{
+				{ID: "art-1", Path: "junit-partition-1.xml", URL: "/download/art-1"},
+			})
+		case "/download/art-1":
+			w.Write([]byte(junit))
+		default:
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClientWithBaseURL(srv.URL, "acme", "agave", "test-token")
+	artifacts, err := client.JobArtifacts(context.Background(), 42, "job-1")
+	if err != nil {
+		t.Fatalf("JobArtifacts: %v", err)
+	}
+	if len(artifacts) != 1 {
+		t.Fatalf("got %d artifacts, want 1", len(artifacts))
+	}
+	artifacts[0].URL = srv.URL + artifacts[0].URL
+
+	data, err := client.DownloadArtifact(context.Background(), artifacts[0])
+	if err != nil {
+		t.Fatalf("DownloadArtifact: %v", err)
+	}
+	if string(data) != junit {
+		t.Fatalf("got %q, want %q", string(data), junit)
+	}
+}
+
+func TestFirstBuildWithCommit(t *testing.T) {
+	builds := []Build{
+		{Number: 2, Commit: "deadbeef"},
+		{Number: 1, Commit: "cafef00d"},
+	}
+
+	if got := firstBuildWithCommit(builds, "cafef00d"); got == nil || got.Number != 1 {
+		t.Fatalf("got %+v, want build 1", got)
+	}
+	if got := firstBuildWithCommit(builds, "unknown-commit"); got != nil {
+		t.Fatalf("got %+v, want nil for an unmatched commit", got)
+	}
+}
+
+func TestClientGetMemoizesIdenticalURLs(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewEncoder(w).Encode([]Build{{Number: 1, State: "passed"}})
+	}))
+	defer srv.Close()
+
+	client := NewClientWithBaseURL(srv.URL, "acme", "agave", "test-token")
+
+	if _, err := client.RecentPassedBuilds(context.Background(), "master", 5); err != nil {
+		t.Fatalf("RecentPassedBuilds: %v", err)
+	}
+	if _, err := client.RecentPassedBuilds(context.Background(), "master", 5); err != nil {
+		t.Fatalf("RecentPassedBuilds: %v", err)
+	}
+
+	if requests != 1 {
+		t.Fatalf("got %d requests, want the second identical call to be served from the in-memory cache", requests)
+	}
+}
@@ -0,0 +1,57 @@
+package partition
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+// junitTestCase is the subset of a JUnit <testcase> element we care about.
+type junitTestCase struct {
+	Name      string  `xml:"name,attr"`
+	ClassName string  `xml:"classname,attr"`
+	Time      float64 `xml:"time,attr"`
+}
+
+type junitTestSuite struct {
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestSuites struct {
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+// ParseJUnit extracts per-test durations from a JUnit XML report. It accepts
+// both a bare <testsuite> root and a <testsuites> wrapper, since cargo's
+// JUnit output and the `nextest`-flavoured reports this repo's test runners
+// produce differ on that point.
+func ParseJUnit(data []byte) ([]TestTiming, error) {
+	var cases []junitTestCase
+
+	var suites junitTestSuites
+	if err := xml.Unmarshal(data, &suites); err == nil && len(suites.TestSuites) > 0 {
+		for _, s := range suites.TestSuites {
+			cases = append(cases, s.TestCases...)
+		}
+	} else {
+		var suite junitTestSuite
+		if err := xml.Unmarshal(data, &suite); err != nil {
+			return nil, fmt.Errorf("parse junit report: %w", err)
+		}
+		cases = suite.TestCases
+	}
+
+	timings := make([]TestTiming, 0, len(cases))
+	for _, c := range cases {
+		name := c.Name
+		if c.ClassName != "" {
+			name = c.ClassName + "::" + c.Name
+		}
+		timings = append(timings, TestTiming{
+			Name:     name,
+			Duration: time.Duration(c.Time * float64(time.Second)),
+		})
+	}
+
+	return timings, nil
+}
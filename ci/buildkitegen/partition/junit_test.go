@@ -0,0 +1,45 @@
+package partition
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseJUnitWrappedSuites(t *testing.T) {
+	const doc = `<?xml version="1.0"?>
+<testsuites>
+  <testsuite name="suite-a">
+    <testcase classname="mod::tests" name="test_one" time="1.5"/>
+    <testcase classname="mod::tests" name="test_two" time="0.25"/>
+  </testsuite>
+</testsuites>`
+
+	timings, err := ParseJUnit([]byte(doc))
+	if err != nil {
+		t.Fatalf("ParseJUnit: %v", err)
+	}
+	if len(timings) != 2 {
+		t.Fatalf("got %d timings, want 2", len(timings))
+	}
+	if timings[0].Name != "mod::tests::test_one" {
+		t.Errorf("got name %q", timings[0].Name)
+	}
+	if timings[0].Duration != 1500*time.Millisecond {
+		t.Errorf("got duration %s, want 1.5s", timings[0].Duration)
+	}
+}
+
+func TestParseJUnitBareSuite(t *testing.T) {
+	const doc = `<?xml version="1.0"?>
+<testsuite name="suite-a">
+  <testcase name="test_one" time="2"/>
+</testsuite>`
+
+	timings, err := ParseJUnit([]byte(doc))
+	if err != nil {
+		t.Fatalf("ParseJUnit: %v", err)
+	}
+	if len(timings) != 1 || timings[0].Name != "test_one" {
+		t.Fatalf("got %+v", timings)
+	}
+}
@@ -0,0 +1,55 @@
+package partition
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAssignBalancesLoad(t *testing.T) {
+	tests := []TestTiming{
+		{Name: "a", Duration: 10 * time.Second},
+		{Name: "b", Duration: 9 * time.Second},
+		{Name: "c", Duration: 8 * time.Second},
+		{Name: "d", Duration: 1 * time.Second},
+		{Name: "e", Duration: 1 * time.Second},
+	}
+
+	shards := Assign(tests, 3)
+	if len(shards) != 3 {
+		t.Fatalf("got %d shards, want 3", len(shards))
+	}
+
+	var total, testCount int
+	for _, s := range shards {
+		total += int(s.Total)
+		testCount += len(s.Tests)
+	}
+	if testCount != len(tests) {
+		t.Fatalf("got %d tests placed, want %d", testCount, len(tests))
+	}
+
+	max, min := shards[0].Total, shards[0].Total
+	for _, s := range shards[1:] {
+		if s.Total > max {
+			max = s.Total
+		}
+		if s.Total < min {
+			min = s.Total
+		}
+	}
+	if max-min > 2*time.Second {
+		t.Errorf("shards too unbalanced: min=%s max=%s", min, max)
+	}
+}
+
+func TestAssignEmptyTests(t *testing.T) {
+	shards := Assign(nil, 3)
+	if len(shards) != 3 {
+		t.Fatalf("got %d shards, want 3", len(shards))
+	}
+	for _, s := range shards {
+		if len(s.Tests) != 0 {
+			t.Errorf("expected empty shard, got %v", s.Tests)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package partition
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// MaxCacheAge is how old a cached or freshly-computed Assignment is allowed
+// to be before Plan refuses to reuse it and falls back to modulo splitting.
+const MaxCacheAge = 7 * 24 * time.Hour
+
+// Options configures Plan for a single step (e.g. "partition").
+type Options struct {
+	// Step is the step name the timings were recorded under, used both as
+	// the cache key and to pick out the right job from each historical
+	// build.
+	Step string
+	// Shards is the number of shards to bin-pack tests into.
+	Shards int
+	// HistoryBuilds is how many recent passed builds on Branch to pull
+	// timings from.
+	HistoryBuilds int
+	// Branch is the branch to query historical builds from, typically
+	// "master".
+	Branch string
+	// ArtifactPath is the JUnit report path uploaded by each shard job,
+	// e.g. "junit-partition-*.xml".
+	ArtifactPath string
+}
+
+// Plan computes a timing-driven shard Assignment for commit. It tries, in
+// order: the local Cache; a prior build for the same commit that already
+// uploaded a cache artifact (see syncCacheFromPriorBuild); and finally
+// recomputing from historical JUnit timings. ok is false when none of those
+// produced usable data (all misses, or all data older than MaxCacheAge);
+// callers should fall back to modulo partitioning in that case.
+func Plan(ctx context.Context, client *Client, cache *Cache, commit string, opts Options) (a *Assignment, ok bool) {
+	if cached, err := cache.Load(commit, opts.Step, opts.Shards); err == nil && cached != nil && !cached.Stale(MaxCacheAge) {
+		return cached, true
+	}
+
+	searchN := opts.HistoryBuilds
+	if commitSearchHistory > searchN {
+		searchN = commitSearchHistory
+	}
+	builds, err := client.RecentPassedBuilds(ctx, opts.Branch, searchN)
+	if err != nil {
+		builds = nil
+	}
+
+	if cached, ok := syncCacheFromPriorBuild(ctx, client, cache, builds, commit, opts.Step, opts.Shards); ok {
+		return cached, true
+	}
+
+	if len(builds) > opts.HistoryBuilds {
+		builds = builds[:opts.HistoryBuilds]
+	}
+
+	timings := collectTimings(ctx, client, builds, opts)
+	if len(timings) == 0 {
+		return nil, false
+	}
+
+	shards := Assign(timings, opts.Shards)
+	assignment := &Assignment{
+		Commit: commit,
+		Step:   opts.Step,
+		Shards: make([][]string, len(shards)),
+	}
+	for i, s := range shards {
+		assignment.Shards[i] = s.Tests
+	}
+	assignment.GeneratedAt = time.Now()
+
+	if err := cache.Save(assignment); err != nil {
+		// A failed cache write doesn't invalidate the assignment we just
+		// computed; the next run just recomputes it too.
+		fmt.Println("partition: failed to cache assignment:", err)
+	} else if err := uploadArtifact(ctx, cache.path(assignment.Commit, assignment.Step, len(assignment.Shards))); err != nil {
+		// Likewise, a failed artifact upload just means a later build for
+		// this commit won't find it and recomputes too.
+		fmt.Println("partition: failed to upload cache artifact:", err)
+	}
+
+	return assignment, true
+}
+
+// syncCacheFromPriorBuild looks for a build in builds matching commit, and
+// if one exists, searches its jobs' artifacts (via the same REST client
+// collectTimings uses, not a CLI subprocess) for the cache file this step
+// would have uploaded. ok is false on any miss, download failure, or a
+// shard-count mismatch/stale result.
+func syncCacheFromPriorBuild(ctx context.Context, client *Client, cache *Cache, builds []Build, commit, step string, shards int) (a *Assignment, ok bool) {
+	prev := firstBuildWithCommit(builds, commit)
+	if prev == nil {
+		return nil, false
+	}
+
+	wantName := cacheFileName(commit, step, shards)
+
+	for _, job := range prev.Jobs {
+		artifacts, err := client.JobArtifacts(ctx, prev.Number, job.ID)
+		if err != nil {
+			continue
+		}
+
+		for _, artifact := range artifacts {
+			if filepath.Base(artifact.Path) != wantName {
+				continue
+			}
+
+			data, err := client.DownloadArtifact(ctx, artifact)
+			if err != nil {
+				continue
+			}
+
+			var cached Assignment
+			if err := json.Unmarshal(data, &cached); err != nil || len(cached.Shards) != shards || cached.Stale(MaxCacheAge) {
+				continue
+			}
+
+			if err := cache.Save(&cached); err != nil {
+				fmt.Println("partition: failed to save downloaded cache artifact:", err)
+			}
+			return &cached, true
+		}
+	}
+
+	return nil, false
+}
+
+// collectTimings downloads each build's JUnit artifact for opts.Step and
+// merges the per-test durations. Durations for a test seen in more than one
+// build are averaged.
+func collectTimings(ctx context.Context, client *Client, builds []Build, opts Options) []TestTiming {
+	type acc struct {
+		total time.Duration
+		count int
+	}
+	totals := map[string]*acc{}
+
+	for _, build := range builds {
+		for _, job := range build.Jobs {
+			if job.StepKey != opts.Step {
+				continue
+			}
+
+			artifacts, err := client.JobArtifacts(ctx, build.Number, job.ID)
+			if err != nil {
+				continue
+			}
+
+			for _, a := range artifacts {
+				if matched, err := doublestar.Match(opts.ArtifactPath, a.Path); err != nil || !matched {
+					continue
+				}
+
+				data, err := client.DownloadArtifact(ctx, a)
+				if err != nil {
+					continue
+				}
+
+				timings, err := ParseJUnit(data)
+				if err != nil {
+					continue
+				}
+
+				for _, t := range timings {
+					e, ok := totals[t.Name]
+					if !ok {
+						e = &acc{}
+						totals[t.Name] = e
+					}
+					e.total += t.Duration
+					e.count++
+				}
+			}
+		}
+	}
+
+	merged := make([]TestTiming, 0, len(totals))
+	for name, e := range totals {
+		merged = append(merged, TestTiming{Name: name, Duration: e.total / time.Duration(e.count)})
+	}
+
+	return merged
+}
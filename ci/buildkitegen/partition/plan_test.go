@@ -0,0 +1,125 @@
+package partition
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPlanMatchesGlobArtifactPathAgainstConcreteNames(t *testing.T) {
+	const junit = `<testsuite>
+  <testcase name="test_a" time="3"/>
+  <testcase name="test_b" time="1"/>
+</testsuite>`
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/organizations/acme/pipelines/agave/builds", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]Build{
+			{
+				Number: 1,
+				State:  "passed",
+				Jobs: []Job{
+					{ID: "job-1", StepKey: "partition", State: "passed"},
+				},
+			},
+		})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("/organizations/acme/pipelines/agave/builds/1/jobs/job-1/artifacts", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]Artifact{
+			{ID: "art-1", Path: "junit-partition-1.xml", URL: srv.URL + "/download/art-1"},
+		})
+	})
+	mux.HandleFunc("/download/art-1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(junit))
+	})
+
+	client := NewClientWithBaseURL(srv.URL, "acme", "agave", "test-token")
+	cache := &Cache{Dir: t.TempDir()}
+
+	assignment, ok := Plan(context.Background(), client, cache, "abc123", Options{
+		Step:          "partition",
+		Shards:        2,
+		HistoryBuilds: 1,
+		Branch:        "master",
+		ArtifactPath:  "junit-partition-*.xml",
+	})
+	if !ok {
+		t.Fatal("Plan returned ok=false; the glob ArtifactPath should have matched the concrete artifact name")
+	}
+	if len(assignment.Shards) != 2 {
+		t.Fatalf("got %d shards, want 2", len(assignment.Shards))
+	}
+
+	var allTests []string
+	for _, shard := range assignment.Shards {
+		allTests = append(allTests, shard...)
+	}
+	if len(allTests) != 2 {
+		t.Fatalf("got tests %v, want both test_a and test_b assigned", allTests)
+	}
+}
+
+func TestPlanReusesCacheArtifactFromPriorBuildOnSameCommit(t *testing.T) {
+	cachedAssignment := Assignment{
+		Commit:      "abc123",
+		Step:        "partition",
+		Shards:      [][]string{{"test_a"}, {"test_b"}},
+		GeneratedAt: time.Now(),
+	}
+	cachedJSON, err := json.Marshal(cachedAssignment)
+	if err != nil {
+		t.Fatalf("marshal fixture: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/organizations/acme/pipelines/agave/builds", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]Build{
+			{
+				Number: 7,
+				Commit: "abc123",
+				State:  "passed",
+				Jobs: []Job{
+					{ID: "job-pipeline-upload", StepKey: "pipeline-upload", State: "passed"},
+				},
+			},
+		})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("/organizations/acme/pipelines/agave/builds/7/jobs/job-pipeline-upload/artifacts", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]Artifact{
+			{ID: "art-1", Path: "abc123-partition-2.json", URL: srv.URL + "/download/cache.json"},
+		})
+	})
+	mux.HandleFunc("/download/cache.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(cachedJSON)
+	})
+
+	client := NewClientWithBaseURL(srv.URL, "acme", "agave", "test-token")
+	cache := &Cache{Dir: t.TempDir()}
+
+	assignment, ok := Plan(context.Background(), client, cache, "abc123", Options{
+		Step:          "partition",
+		Shards:        2,
+		HistoryBuilds: 1,
+		Branch:        "master",
+		ArtifactPath:  "junit-partition-*.xml",
+	})
+	if !ok {
+		t.Fatal("Plan returned ok=false; it should have downloaded the prior build's cache artifact")
+	}
+	if len(assignment.Shards) != 2 || assignment.Shards[0][0] != "test_a" {
+		t.Fatalf("got %+v, want the cached assignment's shards", assignment.Shards)
+	}
+
+	if reloaded, err := cache.Load("abc123", "partition", 2); err != nil || reloaded == nil {
+		t.Fatalf("expected downloaded assignment to be saved to the local cache, got %+v, err=%v", reloaded, err)
+	}
+}
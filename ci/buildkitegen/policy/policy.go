@@ -0,0 +1,127 @@
+// Package policy lets a PR override buildkitegen's changed-file-driven step
+// selection via commit/PR-body trailers (CI-Skip, CI-Force, CI-Only) and
+// labels (ci-full, ci-skip-coverage, ci-sbf-only).
+package policy
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Policy is the union of every trailer/label signal found on a PR.
+type Policy struct {
+	// Only, if non-empty, restricts selection to exactly these step names
+	// (plus always-run steps) and overrides every other signal.
+	Only []string
+	// Skip excludes these step names, overriding path-based inclusion (but
+	// not Only).
+	Skip []string
+	// Force includes these step names even if no changed file matched
+	// their path rule.
+	Force []string
+	// Full runs every step, as if this were a push build.
+	Full bool
+}
+
+// Merge combines two Policies, concatenating their lists and OR-ing Full.
+func (p Policy) Merge(other Policy) Policy {
+	return Policy{
+		Only:  append(append([]string{}, p.Only...), other.Only...),
+		Skip:  append(append([]string{}, p.Skip...), other.Skip...),
+		Force: append(append([]string{}, p.Force...), other.Force...),
+		Full:  p.Full || other.Full,
+	}
+}
+
+var trailerRe = regexp.MustCompile(`(?im)^\s*(CI-Skip|CI-Force|CI-Only)\s*:\s*(.+?)\s*$`)
+
+// ParseTrailers scans text (a PR body and/or concatenated commit messages)
+// for `CI-Skip: a, b`, `CI-Force: c`, and `CI-Only: d` trailer lines.
+func ParseTrailers(text string) Policy {
+	var p Policy
+	for _, m := range trailerRe.FindAllStringSubmatch(text, -1) {
+		names := splitNames(m[2])
+		switch strings.ToLower(m[1]) {
+		case "ci-skip":
+			p.Skip = append(p.Skip, names...)
+		case "ci-force":
+			p.Force = append(p.Force, names...)
+		case "ci-only":
+			p.Only = append(p.Only, names...)
+		}
+	}
+	return p
+}
+
+// knownLabels maps a PR label to the Policy it implies.
+var knownLabels = map[string]Policy{
+	"ci-full":          {Full: true},
+	"ci-skip-coverage": {Skip: []string{"coverage"}},
+	"ci-sbf-only":      {Only: []string{"stable-sbf"}},
+}
+
+// ParseLabels translates PR labels into a Policy.
+func ParseLabels(labels []string) Policy {
+	var p Policy
+	for _, l := range labels {
+		if known, ok := knownLabels[l]; ok {
+			p = p.Merge(known)
+		}
+	}
+	return p
+}
+
+func splitNames(s string) []string {
+	var names []string
+	for _, part := range strings.Split(s, ",") {
+		if name := strings.TrimSpace(part); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve decides whether a step should run, given the policy and the
+// baseline (path-based, already folding in push/always-run) selection
+// cfg.Selected would otherwise produce. An always_run step is immune to
+// every override below CI-Only, since the catalog marks it always_run
+// precisely because it must run on every build. Precedence, highest first:
+//  1. CI-Only, if set at all, fully determines selection.
+//  2. always_run steps always run.
+//  3. CI-Skip excludes a step outright.
+//  4. Otherwise: ci-full label, CI-Force, or the baseline selection.
+func (p Policy) Resolve(stepName string, alwaysRun, baseSelected bool) (selected bool, reason string) {
+	if len(p.Only) > 0 {
+		if alwaysRun || contains(p.Only, stepName) {
+			return true, "CI-Only"
+		}
+		return false, "excluded by CI-Only"
+	}
+
+	if alwaysRun {
+		return true, "always_run"
+	}
+
+	if contains(p.Skip, stepName) {
+		return false, "CI-Skip"
+	}
+
+	if p.Full {
+		return true, "ci-full label"
+	}
+
+	if contains(p.Force, stepName) {
+		return true, "CI-Force"
+	}
+
+	return baseSelected, "path-based selection"
+}
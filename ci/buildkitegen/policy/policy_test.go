@@ -0,0 +1,75 @@
+package policy
+
+import "testing"
+
+func TestParseTrailers(t *testing.T) {
+	const body = `Fixes a bug.
+
+CI-Skip: shellcheck, miri
+CI-Force: coverage
+`
+	p := ParseTrailers(body)
+	if len(p.Skip) != 2 || p.Skip[0] != "shellcheck" || p.Skip[1] != "miri" {
+		t.Fatalf("got Skip=%v", p.Skip)
+	}
+	if len(p.Force) != 1 || p.Force[0] != "coverage" {
+		t.Fatalf("got Force=%v", p.Force)
+	}
+}
+
+func TestParseTrailersOnly(t *testing.T) {
+	p := ParseTrailers("CI-Only: partition")
+	if len(p.Only) != 1 || p.Only[0] != "partition" {
+		t.Fatalf("got Only=%v", p.Only)
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	p := ParseLabels([]string{"ci-full", "unrelated-label"})
+	if !p.Full {
+		t.Error("expected ci-full label to set Full")
+	}
+
+	p = ParseLabels([]string{"ci-skip-coverage"})
+	if len(p.Skip) != 1 || p.Skip[0] != "coverage" {
+		t.Fatalf("got Skip=%v", p.Skip)
+	}
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	only := Policy{Only: []string{"partition"}, Skip: []string{"partition"}}
+	if selected, reason := only.Resolve("partition", false, false); !selected || reason != "CI-Only" {
+		t.Errorf("CI-Only should win over CI-Skip, got selected=%v reason=%q", selected, reason)
+	}
+	if selected, _ := only.Resolve("coverage", false, true); selected {
+		t.Error("steps not named in CI-Only should be excluded")
+	}
+	if selected, _ := only.Resolve("sanity", true, false); !selected {
+		t.Error("always_run steps should still run under CI-Only")
+	}
+
+	skip := Policy{Skip: []string{"coverage"}}
+	if selected, reason := skip.Resolve("coverage", false, true); selected || reason != "CI-Skip" {
+		t.Errorf("CI-Skip should override baseline selection, got selected=%v reason=%q", selected, reason)
+	}
+
+	skipAlwaysRun := Policy{Skip: []string{"sanity"}}
+	if selected, reason := skipAlwaysRun.Resolve("sanity", true, true); !selected || reason != "always_run" {
+		t.Errorf("CI-Skip must not exclude an always_run step, got selected=%v reason=%q", selected, reason)
+	}
+
+	force := Policy{Force: []string{"shuttle"}}
+	if selected, reason := force.Resolve("shuttle", false, false); !selected || reason != "CI-Force" {
+		t.Errorf("CI-Force should select an otherwise-unselected step, got selected=%v reason=%q", selected, reason)
+	}
+
+	full := Policy{Full: true}
+	if selected, _ := full.Resolve("coverage", false, false); !selected {
+		t.Error("ci-full should select every step")
+	}
+
+	var none Policy
+	if selected, reason := none.Resolve("coverage", false, false); selected || reason != "path-based selection" {
+		t.Errorf("got selected=%v reason=%q, want baseline false/path-based", selected, reason)
+	}
+}
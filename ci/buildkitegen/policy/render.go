@@ -0,0 +1,30 @@
+package policy
+
+import "strings"
+
+// RenderMarkdown renders p as a short Markdown summary for a
+// `buildkite-agent annotate` step, so reviewers can see which CI overrides
+// are in effect without reading the PR body or labels themselves.
+func RenderMarkdown(p Policy) string {
+	if !p.Full && len(p.Only) == 0 && len(p.Skip) == 0 && len(p.Force) == 0 {
+		return "No CI-Skip/CI-Force/CI-Only trailers or ci-* labels in effect; steps are selected by changed paths."
+	}
+
+	var b strings.Builder
+	b.WriteString("**CI policy overrides in effect:**\n\n")
+
+	if p.Full {
+		b.WriteString("- `ci-full` label: every step runs\n")
+	}
+	if len(p.Only) > 0 {
+		b.WriteString("- `CI-Only`: " + strings.Join(p.Only, ", ") + " (all other steps skipped)\n")
+	}
+	if len(p.Skip) > 0 {
+		b.WriteString("- `CI-Skip`: " + strings.Join(p.Skip, ", ") + "\n")
+	}
+	if len(p.Force) > 0 {
+		b.WriteString("- `CI-Force`: " + strings.Join(p.Force, ", ") + "\n")
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}